@@ -0,0 +1,57 @@
+/*
+   Copyright 2020 The Compose Specification Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package loader
+
+import (
+	"strings"
+
+	"github.com/compose-spec/compose-go/v2/transform"
+	"github.com/compose-spec/compose-go/v2/tree"
+)
+
+// DefaultOption customizes a single Load (or ApplyDefaults) call, without
+// affecting any other concurrent or subsequent call.
+type DefaultOption = transform.ApplyDefaultsOption
+
+// ApplyDefaults runs the explicit "set defaults" phase on dict: it applies
+// every default registered with transform.RegisterDefault, after
+// normalization and before validation. Load calls this itself; it is
+// exported so callers composing their own loading pipeline can invoke it
+// directly.
+func ApplyDefaults(dict map[string]any, opts ...DefaultOption) error {
+	return transform.ApplyDefaults(dict, opts...)
+}
+
+// WithoutDefault disables the default registered for path (the full dotted
+// path it is registered under, such as "services.*.build.context") for this
+// Load call only, so it leaves the corresponding node untouched when the
+// document doesn't declare one. path is matched exactly: a shorter form
+// such as "build.context" disables nothing.
+func WithoutDefault(path string) DefaultOption {
+	return transform.WithoutDefault(toTreePath(path))
+}
+
+// WithDefault sets value as the default for path (a dotted path such as
+// "networks.*.driver") for this Load call only, so it's used whenever the
+// document doesn't declare one.
+func WithDefault(path string, value any) DefaultOption {
+	return transform.WithDefault(toTreePath(path), value)
+}
+
+func toTreePath(path string) tree.Path {
+	return tree.NewPath(strings.Split(path, ".")...)
+}
@@ -0,0 +1,34 @@
+/*
+   Copyright 2020 The Compose Specification Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package loader
+
+// Load runs the registered "set defaults" phase against dict — the raw,
+// normalized map this Compose YAML document decoded into — and returns it
+// ready for validation. It is the single place ApplyDefaults is invoked
+// from, so a caller driving the loader never needs to remember to call it
+// themselves; direct callers of ApplyDefaults (e.g. tooling composing its
+// own pipeline around the normalization/validation steps this package
+// doesn't yet expose) remain supported as before.
+//
+// opts (WithoutDefault, WithDefault) apply to this Load call only; they
+// don't change what any other, concurrent or subsequent, Load call sees.
+func Load(dict map[string]any, opts ...DefaultOption) (map[string]any, error) {
+	if err := ApplyDefaults(dict, opts...); err != nil {
+		return nil, err
+	}
+	return dict, nil
+}
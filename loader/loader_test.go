@@ -0,0 +1,111 @@
+/*
+   Copyright 2020 The Compose Specification Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package loader
+
+import "testing"
+
+func TestLoadAppliesDefaults(t *testing.T) {
+	dict := map[string]any{
+		"services": map[string]any{
+			"web": map[string]any{
+				"build": map[string]any{},
+			},
+		},
+	}
+
+	out, err := Load(dict)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	build := out["services"].(map[string]any)["web"].(map[string]any)["build"].(map[string]any)
+	if build["context"] != "." {
+		t.Fatalf("expected Load to apply the build.context default, got %v", build["context"])
+	}
+}
+
+func TestWithoutDefaultSuppressesDefaultInLoad(t *testing.T) {
+	dict := map[string]any{
+		"services": map[string]any{
+			"web": map[string]any{
+				"build": map[string]any{},
+			},
+		},
+	}
+
+	out, err := Load(dict, WithoutDefault("services.*.build.context"))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	build := out["services"].(map[string]any)["web"].(map[string]any)["build"].(map[string]any)
+	if _, ok := build["context"]; ok {
+		t.Fatalf("expected no build.context default once suppressed, got %v", build["context"])
+	}
+
+	// A later Load without the option must still see the default - proving
+	// WithoutDefault scoped to the call above, not to the package.
+	other := map[string]any{
+		"services": map[string]any{
+			"db": map[string]any{
+				"build": map[string]any{},
+			},
+		},
+	}
+	out, err = Load(other)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	db := out["services"].(map[string]any)["db"].(map[string]any)["build"].(map[string]any)
+	if db["context"] != "." {
+		t.Fatalf("expected a later Load call to still get the default, got %v", db["context"])
+	}
+}
+
+func TestWithDefaultOverridesForOneLoadCallOnly(t *testing.T) {
+	dict := map[string]any{
+		"services": map[string]any{
+			"web": map[string]any{
+				"build": map[string]any{},
+			},
+		},
+	}
+
+	out, err := Load(dict, WithDefault("services.*.build.context", "./custom"))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	build := out["services"].(map[string]any)["web"].(map[string]any)["build"].(map[string]any)
+	if build["context"] != "./custom" {
+		t.Fatalf("got %v, want ./custom", build["context"])
+	}
+
+	other := map[string]any{
+		"services": map[string]any{
+			"db": map[string]any{
+				"build": map[string]any{},
+			},
+		},
+	}
+	out, err = Load(other)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	db := out["services"].(map[string]any)["db"].(map[string]any)["build"].(map[string]any)
+	if db["context"] != "." {
+		t.Fatalf("expected a later Load call to see the built-in default again, got %v", db["context"])
+	}
+}
@@ -24,9 +24,9 @@ import (
 func transformBuild(data any, p tree.Path) (any, error) {
 	switch v := data.(type) {
 	case map[string]any:
-		if _, ok := v["context"]; !ok {
-			v["context"] = "." // TODO(ndeloof) maybe we miss an explicit "set-defaults" loading phase
-		}
+		// build.context's default is set by the explicit "set defaults"
+		// phase (see transform.ApplyDefaults), which runs after
+		// normalization and before validation.
 		return transformMapping(v, p)
 	case string:
 		return map[string]any{
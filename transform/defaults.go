@@ -0,0 +1,158 @@
+/*
+   Copyright 2020 The Compose Specification Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package transform
+
+import (
+	"strings"
+
+	"github.com/compose-spec/compose-go/v2/tree"
+)
+
+// pathMatchAll is the wildcard path segment matching any map key, mirroring
+// how transformer paths already address "services.*.build" style nodes.
+const pathMatchAll = "*"
+
+// Defaulter computes the default value for the node at path when the loaded
+// document doesn't declare one. data is the node's current raw value (nil
+// if the key is absent). Returning ok=false leaves the node untouched.
+type Defaulter func(data any, p tree.Path) (value any, ok bool)
+
+// defaulters is the init table migrated defaults are registered into,
+// keyed by the dotted path they apply to, exactly like the transformer map
+// this subsystem takes over defaulting duties from. Unlike
+// ApplyDefaultsOption below, this table is meant to be populated once, at
+// init time, by whoever embeds this package (bake, compose v2, abra, ...),
+// not mutated per call.
+var defaulters = map[tree.Path]Defaulter{}
+
+func init() {
+	RegisterDefault(tree.NewPath("services", pathMatchAll, "build", "context"), func(data any, _ tree.Path) (any, bool) {
+		if data != nil {
+			return nil, false
+		}
+		return ".", true
+	})
+}
+
+// RegisterDefault registers the default value setter for path, so it runs
+// during the explicit "set defaults" phase instead of being hardcoded into
+// a transformer. External tools can use this to add their own defaults —
+// bake wants `dockerfile: Dockerfile`, compose v2 wants `driver: bridge` for
+// networks, abra wants `restart: unless-stopped`.
+func RegisterDefault(path tree.Path, defaulter Defaulter) {
+	defaulters[path] = defaulter
+}
+
+// applyDefaultsOptions holds the per-call overrides ApplyDefaultsOption
+// applies on top of the (shared, registered) defaulters table, without
+// mutating it.
+type applyDefaultsOptions struct {
+	without   map[tree.Path]bool
+	overrides map[tree.Path]Defaulter
+}
+
+// ApplyDefaultsOption customizes a single ApplyDefaults call.
+type ApplyDefaultsOption func(*applyDefaultsOptions)
+
+// WithoutDefault disables the registered default for path for this
+// ApplyDefaults call only; other concurrent or subsequent calls are
+// unaffected.
+func WithoutDefault(path tree.Path) ApplyDefaultsOption {
+	return func(o *applyDefaultsOptions) {
+		o.without[path] = true
+	}
+}
+
+// WithDefault overrides the default value used at path with a constant for
+// this ApplyDefaults call only, without needing to write a Defaulter
+// function or touching the registered default other callers see. It also
+// applies to a path with no registered default. A value the document
+// already declares is left as it is.
+func WithDefault(path tree.Path, value any) ApplyDefaultsOption {
+	return func(o *applyDefaultsOptions) {
+		o.overrides[path] = func(data any, _ tree.Path) (any, bool) {
+			if data != nil {
+				return nil, false
+			}
+			return value, true
+		}
+	}
+}
+
+// ApplyDefaults runs the explicit "set defaults" phase: after normalization
+// and before validation, every registered Defaulter is applied to every
+// node of dict its path matches, except where opts disables or overrides it
+// for this call. A WithDefault for a path with no registered default is
+// applied the same way.
+func ApplyDefaults(dict map[string]any, opts ...ApplyDefaultsOption) error {
+	options := applyDefaultsOptions{
+		without:   map[tree.Path]bool{},
+		overrides: map[tree.Path]Defaulter{},
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	for path, defaulter := range defaulters {
+		if options.without[path] {
+			continue
+		}
+		if override, ok := options.overrides[path]; ok {
+			defaulter = override
+		}
+		applyDefault(dict, strings.Split(string(path), "."), defaulter)
+	}
+	for path, override := range options.overrides {
+		if _, registered := defaulters[path]; registered || options.without[path] {
+			continue
+		}
+		applyDefault(dict, strings.Split(string(path), "."), override)
+	}
+	return nil
+}
+
+// applyDefault walks node following parts, matching pathMatchAll against
+// any key, and invokes defaulter on the map holding the leaf segment once
+// reached.
+func applyDefault(node any, parts []string, defaulter Defaulter) {
+	if len(parts) == 0 {
+		return
+	}
+	m, ok := node.(map[string]any)
+	if !ok {
+		return
+	}
+
+	if len(parts) == 1 {
+		key := parts[0]
+		if value, set := defaulter(m[key], tree.NewPath(key)); set {
+			m[key] = value
+		}
+		return
+	}
+
+	part := parts[0]
+	if part == pathMatchAll {
+		for _, child := range m {
+			applyDefault(child, parts[1:], defaulter)
+		}
+		return
+	}
+	if child, ok := m[part]; ok {
+		applyDefault(child, parts[1:], defaulter)
+	}
+}
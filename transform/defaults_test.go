@@ -0,0 +1,127 @@
+/*
+   Copyright 2020 The Compose Specification Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package transform
+
+import (
+	"testing"
+
+	"github.com/compose-spec/compose-go/v2/tree"
+)
+
+func TestApplyDefaultsBuildContext(t *testing.T) {
+	dict := map[string]any{
+		"services": map[string]any{
+			"web": map[string]any{
+				"build": map[string]any{},
+			},
+			"db": map[string]any{
+				"build": map[string]any{
+					"context": "./db",
+				},
+			},
+		},
+	}
+
+	if err := ApplyDefaults(dict); err != nil {
+		t.Fatalf("ApplyDefaults: %v", err)
+	}
+
+	web := dict["services"].(map[string]any)["web"].(map[string]any)["build"].(map[string]any)
+	if web["context"] != "." {
+		t.Fatalf("expected web build.context to default to \".\", got %v", web["context"])
+	}
+
+	db := dict["services"].(map[string]any)["db"].(map[string]any)["build"].(map[string]any)
+	if db["context"] != "./db" {
+		t.Fatalf("expected db build.context to keep its explicit value, got %v", db["context"])
+	}
+}
+
+func TestRegisterDefaultWildcardMatchesEveryKey(t *testing.T) {
+	path := tree.NewPath("networks", pathMatchAll, "driver")
+	RegisterDefault(path, func(data any, _ tree.Path) (any, bool) {
+		if data != nil {
+			return nil, false
+		}
+		return "bridge", true
+	})
+	defer delete(defaulters, path)
+
+	dict := map[string]any{
+		"networks": map[string]any{
+			"default": map[string]any{},
+			"custom":  map[string]any{"driver": "overlay"},
+		},
+	}
+	if err := ApplyDefaults(dict); err != nil {
+		t.Fatalf("ApplyDefaults: %v", err)
+	}
+
+	networks := dict["networks"].(map[string]any)
+	if got := networks["default"].(map[string]any)["driver"]; got != "bridge" {
+		t.Fatalf("expected default network driver to default to bridge, got %v", got)
+	}
+	if got := networks["custom"].(map[string]any)["driver"]; got != "overlay" {
+		t.Fatalf("expected custom network driver to keep its explicit value, got %v", got)
+	}
+}
+
+func TestWithDefaultAppliesOnlyToThisCall(t *testing.T) {
+	path := tree.NewPath("x-test-default")
+
+	dict := map[string]any{}
+	if err := ApplyDefaults(dict, WithDefault(path, "constant-value")); err != nil {
+		t.Fatalf("ApplyDefaults: %v", err)
+	}
+	if dict["x-test-default"] != "constant-value" {
+		t.Fatalf("got %v, want constant-value", dict["x-test-default"])
+	}
+
+	// A second, plain call must not see the override from the first.
+	other := map[string]any{}
+	if err := ApplyDefaults(other); err != nil {
+		t.Fatalf("ApplyDefaults: %v", err)
+	}
+	if _, ok := other["x-test-default"]; ok {
+		t.Fatalf("expected WithDefault to not leak into a later call without it, got %v", other["x-test-default"])
+	}
+}
+
+func TestWithoutDefaultSuppressesRegisteredDefaultForThisCallOnly(t *testing.T) {
+	path := tree.NewPath("x-test-suppressed")
+	RegisterDefault(path, func(any, tree.Path) (any, bool) {
+		return "should-not-appear-when-suppressed", true
+	})
+	defer delete(defaulters, path)
+
+	suppressed := map[string]any{}
+	if err := ApplyDefaults(suppressed, WithoutDefault(path)); err != nil {
+		t.Fatalf("ApplyDefaults: %v", err)
+	}
+	if _, ok := suppressed["x-test-suppressed"]; ok {
+		t.Fatalf("expected the default to be suppressed, got %v", suppressed["x-test-suppressed"])
+	}
+
+	// A second, plain call must still see the registered default.
+	other := map[string]any{}
+	if err := ApplyDefaults(other); err != nil {
+		t.Fatalf("ApplyDefaults: %v", err)
+	}
+	if other["x-test-suppressed"] != "should-not-appear-when-suppressed" {
+		t.Fatalf("expected WithoutDefault to not leak into a later call without it, got %v", other["x-test-suppressed"])
+	}
+}
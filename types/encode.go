@@ -0,0 +1,303 @@
+/*
+   Copyright 2020 The Compose Specification Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// encodeSectionOrder is the documented canonical order EncodeYAML and
+// EncodeJSON emit top-level Project sections in: name, services, networks,
+// volumes, configs, secrets, then extensions sorted by key.
+var encodeSectionOrder = []string{"name", "services", "networks", "volumes", "configs", "secrets"}
+
+type encodeOptions struct {
+	canonicalKeyOrder bool
+	withoutDefaults   bool
+	resolvedPaths     bool
+	redactSecrets     bool
+}
+
+// EncodeOption customizes EncodeYAML and EncodeJSON.
+type EncodeOption func(*encodeOptions)
+
+// WithCanonicalKeyOrder emits extensions sorted by key. This is the
+// default; it only exists to be paired with WithRawKeyOrder so callers can
+// restore it after passing that option to some other, shared EncodeOption
+// slice.
+func WithCanonicalKeyOrder() EncodeOption {
+	return func(o *encodeOptions) {
+		o.canonicalKeyOrder = true
+	}
+}
+
+// WithRawKeyOrder emits extensions in whatever order Project.Extensions
+// happens to iterate in, instead of sorted by key. The fixed sections
+// (name, services, networks, volumes, configs, secrets) always keep their
+// documented position regardless, since Project doesn't store those in a
+// map to begin with — only the x-* extensions have no inherent order.
+func WithRawKeyOrder() EncodeOption {
+	return func(o *encodeOptions) {
+		o.canonicalKeyOrder = false
+	}
+}
+
+// WithoutDefaults omits fields that are equal to their compose-spec default,
+// producing a minimal document.
+func WithoutDefaults() EncodeOption {
+	return func(o *encodeOptions) {
+		o.withoutDefaults = true
+	}
+}
+
+// WithResolvedPaths rewrites relative build context and bind mount paths to
+// be absolute, resolved against the Project's WorkingDir.
+func WithResolvedPaths() EncodeOption {
+	return func(o *encodeOptions) {
+		o.resolvedPaths = true
+	}
+}
+
+// WithRedactedSecrets replaces the file path of every secret and config
+// that declares one with a placeholder, so the emitted document can be
+// shared without revealing where their values are stored.
+func WithRedactedSecrets() EncodeOption {
+	return func(o *encodeOptions) {
+		o.redactSecrets = true
+	}
+}
+
+// EncodeYAML streams the project as YAML to w, one top-level section at a
+// time, in canonical key order. Unlike MarshalYAML, it does not rely on
+// gopkg.in/yaml.v3's map encoding (which gives no key-ordering guarantee),
+// and each section is marshaled and written on its own rather than first
+// being assembled into one in-memory document: peak memory is the size of
+// the largest single section, not the whole project twice over (except that
+// WithoutDefaults, WithResolvedPaths and WithRedactedSecrets work on a
+// deep copy of the project).
+func (p *Project) EncodeYAML(w io.Writer, opts ...EncodeOption) error {
+	options := encodeOptions{canonicalKeyOrder: true}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	project := p.prepareForEncode(options)
+
+	first := true
+	for _, key := range project.encodeSections(options) {
+		value := project.section(key)
+		if isEmptySection(value) {
+			continue
+		}
+		if !first {
+			if _, err := io.WriteString(w, "\n"); err != nil {
+				return err
+			}
+		}
+		first = false
+		if err := encodeYAMLSection(w, key, value); err != nil {
+			return fmt.Errorf("encoding %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// encodeYAMLSection writes a single `key: value` block to w. Routing it
+// through a single-entry map keeps key and value together in one
+// yaml.Encoder call (needed for correct indentation) without pulling any
+// other section into memory alongside it.
+func encodeYAMLSection(w io.Writer, key string, value any) error {
+	valueNode := &yaml.Node{}
+	if err := valueNode.Encode(value); err != nil {
+		return err
+	}
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(yaml.Node{
+		Kind: yaml.MappingNode,
+		Content: []*yaml.Node{
+			{Kind: yaml.ScalarNode, Value: key},
+			valueNode,
+		},
+	}); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// EncodeJSON streams the project as JSON to w, writing one top-level
+// section at a time in canonical key order.
+func (p *Project) EncodeJSON(w io.Writer, opts ...EncodeOption) error {
+	options := encodeOptions{canonicalKeyOrder: true}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	project := p.prepareForEncode(options)
+
+	if _, err := io.WriteString(w, "{"); err != nil {
+		return err
+	}
+	first := true
+	for _, key := range project.encodeSections(options) {
+		value := project.section(key)
+		if isEmptySection(value) {
+			continue
+		}
+		if !first {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+		first = false
+
+		keyJSON, err := json.Marshal(key)
+		if err != nil {
+			return err
+		}
+		valueJSON, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("encoding %s: %w", key, err)
+		}
+		if _, err := fmt.Fprintf(w, "%s:%s", keyJSON, valueJSON); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, "}")
+	return err
+}
+
+// encodeSections returns the top-level section keys to emit, in order. The
+// fixed sections always keep their documented position; only the order of
+// the x-* extensions depends on options.canonicalKeyOrder, since they're
+// the one part of this list that actually comes from a map.
+func (p *Project) encodeSections(options encodeOptions) []string {
+	keys := append([]string{}, encodeSectionOrder...)
+	extensions := make([]string, 0, len(p.Extensions))
+	for k := range p.Extensions {
+		extensions = append(extensions, k)
+	}
+	if options.canonicalKeyOrder {
+		sort.Strings(extensions)
+	}
+	return append(keys, extensions...)
+}
+
+func (p *Project) section(key string) any {
+	switch key {
+	case "name":
+		return p.Name
+	case "services":
+		return p.Services
+	case "networks":
+		return p.Networks
+	case "volumes":
+		return p.Volumes
+	case "configs":
+		return p.Configs
+	case "secrets":
+		return p.Secrets
+	default:
+		return p.Extensions[key]
+	}
+}
+
+func isEmptySection(value any) bool {
+	switch v := value.(type) {
+	case string:
+		return v == ""
+	case Services:
+		return len(v) == 0
+	case Networks:
+		return len(v) == 0
+	case Volumes:
+		return len(v) == 0
+	case Configs:
+		return len(v) == 0
+	case Secrets:
+		return len(v) == 0
+	default:
+		return value == nil
+	}
+}
+
+// defaultNetworkDriver is the driver the compose-spec assumes for a
+// network that doesn't declare one explicitly.
+const defaultNetworkDriver = "bridge"
+
+// prepareForEncode applies WithoutDefaults, WithResolvedPaths and
+// WithRedactedSecrets to a deep copy of the project, leaving the original
+// untouched. When none of those options are set, it returns p itself: the
+// whole point of Encode{YAML,JSON} is to avoid materializing a second copy
+// of a potentially large project just to read it back out.
+func (p *Project) prepareForEncode(options encodeOptions) *Project {
+	if !options.resolvedPaths && !options.redactSecrets && !options.withoutDefaults {
+		return p
+	}
+
+	project := p.deepCopy()
+	if options.resolvedPaths {
+		for name, service := range project.Services {
+			if service.Build != nil && service.Build.Context != "" {
+				service.Build.Context = project.RelativePath(service.Build.Context)
+			}
+			for i, vol := range service.Volumes {
+				if vol.Type == VolumeTypeBind && vol.Source != "" {
+					vol.Source = project.RelativePath(vol.Source)
+					service.Volumes[i] = vol
+				}
+			}
+			project.Services[name] = service
+		}
+	}
+	if options.redactSecrets {
+		const redacted = "***"
+		for name, secret := range project.Secrets {
+			if secret.File != "" {
+				secret.File = redacted
+			}
+			project.Secrets[name] = secret
+		}
+		for name, config := range project.Configs {
+			if config.File != "" {
+				config.File = redacted
+			}
+			project.Configs[name] = config
+		}
+	}
+	if options.withoutDefaults {
+		for name, service := range project.Services {
+			if service.Build != nil && service.Build.Context == "." {
+				service.Build.Context = ""
+			}
+			project.Services[name] = service
+		}
+		for name, network := range project.Networks {
+			if network.Driver == defaultNetworkDriver {
+				network.Driver = ""
+			}
+			project.Networks[name] = network
+		}
+	}
+	return project
+}
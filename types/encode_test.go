@@ -0,0 +1,187 @@
+/*
+   Copyright 2020 The Compose Specification Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package types
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func encodeFixture() *Project {
+	return &Project{
+		Name: "myapp",
+		Services: Services{
+			"web": ServiceConfig{Name: "web", Image: "nginx", Build: &BuildConfig{Context: "."}},
+		},
+		Networks: Networks{
+			"default": NetworkConfig{Driver: "bridge"},
+		},
+		Extensions: Extensions{
+			"x-foo": "bar",
+		},
+	}
+}
+
+func TestEncodeJSONCanonicalOrder(t *testing.T) {
+	var buf bytes.Buffer
+	if err := encodeFixture().EncodeJSON(&buf); err != nil {
+		t.Fatalf("EncodeJSON: %v", err)
+	}
+	out := buf.String()
+
+	for _, pair := range [][2]string{
+		{`"name"`, `"services"`},
+		{`"services"`, `"networks"`},
+		{`"networks"`, `"x-foo"`},
+	} {
+		if strings.Index(out, pair[0]) >= strings.Index(out, pair[1]) {
+			t.Fatalf("expected %s before %s in %s", pair[0], pair[1], out)
+		}
+	}
+}
+
+func TestEncodeSectionsCanonicalOrderSortsExtensions(t *testing.T) {
+	p := &Project{
+		Extensions: Extensions{"x-zeta": 1, "x-alpha": 2, "x-mid": 3},
+	}
+	keys := p.encodeSections(encodeOptions{canonicalKeyOrder: true})
+	got := keys[len(encodeSectionOrder):]
+	want := []string{"x-alpha", "x-mid", "x-zeta"}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+}
+
+func TestEncodeSectionsRawOrderKeepsFixedSectionsFirstAndAllExtensions(t *testing.T) {
+	p := &Project{
+		Extensions: Extensions{"x-zeta": 1, "x-alpha": 2, "x-mid": 3},
+	}
+	keys := p.encodeSections(encodeOptions{canonicalKeyOrder: false})
+
+	for i, want := range encodeSectionOrder {
+		if keys[i] != want {
+			t.Fatalf("expected fixed section %q at position %d, got %q", want, i, keys[i])
+		}
+	}
+
+	extensions := keys[len(encodeSectionOrder):]
+	seen := map[string]bool{}
+	for _, k := range extensions {
+		seen[k] = true
+	}
+	for want := range p.Extensions {
+		if !seen[want] {
+			t.Fatalf("expected raw order to still include extension %q, got %v", want, extensions)
+		}
+	}
+}
+
+func TestEncodeJSONOmitsEmptySections(t *testing.T) {
+	var buf bytes.Buffer
+	p := &Project{
+		Name:     "myapp",
+		Services: Services{"web": ServiceConfig{Name: "web", Image: "nginx"}},
+	}
+	if err := p.EncodeJSON(&buf); err != nil {
+		t.Fatalf("EncodeJSON: %v", err)
+	}
+	if strings.Contains(buf.String(), `"networks"`) {
+		t.Fatalf("expected no networks key for a project with no networks, got %s", buf.String())
+	}
+}
+
+func TestEncodeYAMLWritesAllSections(t *testing.T) {
+	var buf bytes.Buffer
+	if err := encodeFixture().EncodeYAML(&buf); err != nil {
+		t.Fatalf("EncodeYAML: %v", err)
+	}
+	out := buf.String()
+	for _, key := range []string{"name:", "services:", "networks:", "x-foo:"} {
+		if !strings.Contains(out, key) {
+			t.Errorf("expected output to contain %q, got:\n%s", key, out)
+		}
+	}
+}
+
+func TestPrepareForEncodeSkipsCopyWhenNoOptionsSet(t *testing.T) {
+	p := encodeFixture()
+	got := p.prepareForEncode(encodeOptions{})
+	if got != p {
+		t.Fatal("expected prepareForEncode to return the same project instance when no option is set")
+	}
+}
+
+func TestPrepareForEncodeWithoutDefaultsStripsDefaults(t *testing.T) {
+	p := encodeFixture()
+	got := p.prepareForEncode(encodeOptions{withoutDefaults: true})
+
+	if got == p {
+		t.Fatal("expected prepareForEncode to return a copy when withoutDefaults is set")
+	}
+	if got.Services["web"].Build.Context != "" {
+		t.Fatalf("expected build.context %q to be stripped, got %q", ".", got.Services["web"].Build.Context)
+	}
+	if got.Networks["default"].Driver != "" {
+		t.Fatalf("expected network driver %q to be stripped, got %q", "bridge", got.Networks["default"].Driver)
+	}
+	if p.Services["web"].Build.Context != "." {
+		t.Fatal("prepareForEncode must not mutate the original project")
+	}
+}
+
+func TestPrepareForEncodeWithoutDefaultsKeepsNonDefaultValues(t *testing.T) {
+	p := &Project{
+		Services: Services{
+			"web": ServiceConfig{Name: "web", Build: &BuildConfig{Context: "./web"}},
+		},
+		Networks: Networks{
+			"default": NetworkConfig{Driver: "overlay"},
+		},
+	}
+	got := p.prepareForEncode(encodeOptions{withoutDefaults: true})
+	if got.Services["web"].Build.Context != "./web" {
+		t.Fatalf("expected a non-default build context to be kept, got %q", got.Services["web"].Build.Context)
+	}
+	if got.Networks["default"].Driver != "overlay" {
+		t.Fatalf("expected a non-default network driver to be kept, got %q", got.Networks["default"].Driver)
+	}
+}
+
+func TestIsEmptySection(t *testing.T) {
+	cases := []struct {
+		name  string
+		value any
+		want  bool
+	}{
+		{"empty string", "", true},
+		{"non-empty string", "x", false},
+		{"empty services", Services{}, true},
+		{"non-empty services", Services{"web": ServiceConfig{}}, false},
+		{"nil extension", nil, true},
+	}
+	for _, tc := range cases {
+		if got := isEmptySection(tc.value); got != tc.want {
+			t.Errorf("%s: isEmptySection() = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
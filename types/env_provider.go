@@ -0,0 +1,146 @@
+/*
+   Copyright 2020 The Compose Specification Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package types
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+
+	"github.com/compose-spec/compose-go/v2/dotenv"
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// EnvProvider loads the raw content an EnvFile reference points to. Ref is
+// the EnvFile's Path, which for schemes other than "file" is a URL
+// (e.g. "vault://secret/data/app?field=env", "sops://config/app.env.enc").
+type EnvProvider interface {
+	Load(ctx context.Context, ref string) (io.ReadCloser, error)
+}
+
+// fileEnvProvider is the default provider backing the historical behavior of
+// WithServicesEnvironmentResolved: read the file straight off disk.
+type fileEnvProvider struct{}
+
+func (fileEnvProvider) Load(_ context.Context, ref string) (io.ReadCloser, error) {
+	f, err := os.Open(ref)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// envProviderScheme extracts the scheme providers are registered under for
+// a given EnvFile.Path. A path with no scheme (a plain filesystem path) is
+// always handled by the "file" provider.
+func envProviderScheme(ref string) string {
+	u, err := url.Parse(ref)
+	if err != nil || u.Scheme == "" {
+		return "file"
+	}
+	return u.Scheme
+}
+
+// decodeEnv parses env file content according to format, which mirrors
+// EnvFile.Format ("dotenv", the default, "json" or "yaml").
+func decodeEnv(format string, content []byte, resolve dotenv.LookupFn) (map[string]string, error) {
+	switch format {
+	case "", "dotenv":
+		return dotenv.ParseWithLookup(bytes.NewBuffer(content), resolve)
+	case "json":
+		var m map[string]string
+		if err := json.Unmarshal(content, &m); err != nil {
+			return nil, err
+		}
+		return m, nil
+	case "yaml":
+		var m map[string]string
+		if err := yaml.Unmarshal(content, &m); err != nil {
+			return nil, err
+		}
+		return m, nil
+	default:
+		return nil, fmt.Errorf("unsupported env file format: %s", format)
+	}
+}
+
+// WithServicesEnvironmentResolvedCtx parses env_files set for services to
+// resolve the actual environment map for services, like
+// WithServicesEnvironmentResolved, but loads each EnvFile through the
+// EnvProvider registered for its URL scheme in providers (e.g. "vault",
+// "sops", "sm" for AWS Secrets Manager), falling back to the default "file"
+// provider for plain paths and for any scheme missing from providers.
+// It returns a new Project instance with the changes and keeps the original
+// Project unchanged.
+func (p *Project) WithServicesEnvironmentResolvedCtx(ctx context.Context, discardEnvFiles bool, providers map[string]EnvProvider) (*Project, error) {
+	newProject := p.deepCopy()
+	for i, service := range newProject.Services {
+		service.Environment = service.Environment.Resolve(newProject.Environment.Resolve)
+
+		environment := MappingWithEquals{}
+		var resolve dotenv.LookupFn = func(s string) (string, bool) {
+			v, ok := environment[s]
+			if ok && v != nil {
+				return *v, ok
+			}
+			return newProject.Environment.Resolve(s)
+		}
+
+		for _, envFile := range service.EnvFiles {
+			scheme := envProviderScheme(envFile.Path)
+			provider, ok := providers[scheme]
+			if !ok {
+				provider = fileEnvProvider{}
+			}
+
+			rc, err := provider.Load(ctx, envFile.Path)
+			if err != nil {
+				if os.IsNotExist(err) {
+					if !envFile.Required {
+						continue
+					}
+					return nil, errors.Wrapf(err, "env file %s not found", envFile.Path)
+				}
+				return nil, errors.Wrapf(err, "failed to load %s", envFile.Path)
+			}
+			b, err := io.ReadAll(rc)
+			_ = rc.Close()
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to load %s", envFile.Path)
+			}
+
+			fileVars, err := decodeEnv(envFile.Format, b, resolve)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to read %s", envFile.Path)
+			}
+			environment = environment.OverrideBy(Mapping(fileVars).ToMappingWithEquals())
+		}
+
+		service.Environment = environment.OverrideBy(service.Environment)
+
+		if discardEnvFiles {
+			service.EnvFiles = nil
+		}
+		newProject.Services[i] = service
+	}
+	return newProject, nil
+}
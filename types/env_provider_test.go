@@ -0,0 +1,207 @@
+/*
+   Copyright 2020 The Compose Specification Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package types
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/compose-spec/compose-go/v2/dotenv"
+)
+
+func TestEnvProviderScheme(t *testing.T) {
+	cases := []struct {
+		ref  string
+		want string
+	}{
+		{"/etc/app/.env", "file"},
+		{".env", "file"},
+		{"vault://secret/data/app?field=env", "vault"},
+		{"sops://config/app.env.enc", "sops"},
+	}
+	for _, tc := range cases {
+		if got := envProviderScheme(tc.ref); got != tc.want {
+			t.Errorf("envProviderScheme(%q) = %q, want %q", tc.ref, got, tc.want)
+		}
+	}
+}
+
+func TestDecodeEnvJSON(t *testing.T) {
+	var resolve dotenv.LookupFn = func(string) (string, bool) { return "", false }
+	got, err := decodeEnv("json", []byte(`{"A":"1","B":"2"}`), resolve)
+	if err != nil {
+		t.Fatalf("decodeEnv: %v", err)
+	}
+	if got["A"] != "1" || got["B"] != "2" {
+		t.Fatalf("got %v", got)
+	}
+}
+
+func TestDecodeEnvYAML(t *testing.T) {
+	var resolve dotenv.LookupFn = func(string) (string, bool) { return "", false }
+	got, err := decodeEnv("yaml", []byte("A: \"1\"\nB: \"2\"\n"), resolve)
+	if err != nil {
+		t.Fatalf("decodeEnv: %v", err)
+	}
+	if got["A"] != "1" || got["B"] != "2" {
+		t.Fatalf("got %v", got)
+	}
+}
+
+func TestDecodeEnvUnsupportedFormat(t *testing.T) {
+	var resolve dotenv.LookupFn = func(string) (string, bool) { return "", false }
+	if _, err := decodeEnv("toml", []byte("A=1"), resolve); err == nil {
+		t.Fatal("expected an error for an unsupported format")
+	}
+}
+
+// fakeEnvProvider serves fixed content for any ref, recording which refs it
+// was asked to load.
+type fakeEnvProvider struct {
+	content []byte
+	loaded  []string
+}
+
+func (p *fakeEnvProvider) Load(_ context.Context, ref string) (io.ReadCloser, error) {
+	p.loaded = append(p.loaded, ref)
+	return io.NopCloser(strings.NewReader(string(p.content))), nil
+}
+
+func TestWithServicesEnvironmentResolvedCtxDispatchesByScheme(t *testing.T) {
+	p := &Project{
+		Services: Services{
+			"web": ServiceConfig{
+				Name: "web",
+				EnvFiles: []EnvFile{
+					{Path: "vault://secret/app", Format: "json"},
+				},
+			},
+		},
+	}
+	provider := &fakeEnvProvider{content: []byte(`{"KEY":"VALUE"}`)}
+
+	resolved, err := p.WithServicesEnvironmentResolvedCtx(context.Background(), true, map[string]EnvProvider{"vault": provider})
+	if err != nil {
+		t.Fatalf("WithServicesEnvironmentResolvedCtx: %v", err)
+	}
+	if len(provider.loaded) != 1 || provider.loaded[0] != "vault://secret/app" {
+		t.Fatalf("expected the vault provider to be asked to load the ref, got %v", provider.loaded)
+	}
+	v := resolved.Services["web"].Environment["KEY"]
+	if v == nil || *v != "VALUE" {
+		t.Fatalf("got environment %v, want KEY=VALUE", resolved.Services["web"].Environment)
+	}
+	if resolved.Services["web"].EnvFiles != nil {
+		t.Fatal("expected discardEnvFiles=true to clear EnvFiles")
+	}
+}
+
+func TestWithServicesEnvironmentResolvedCtxFallsBackToFileProvider(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "env-*.json")
+	if err != nil {
+		t.Fatalf("CreateTemp: %v", err)
+	}
+	if _, err := f.WriteString(`{"KEY":"FROMFILE"}`); err != nil {
+		t.Fatalf("WriteString: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	p := &Project{
+		Services: Services{
+			"web": ServiceConfig{
+				Name:     "web",
+				EnvFiles: []EnvFile{{Path: f.Name(), Format: "json"}},
+			},
+		},
+	}
+
+	resolved, err := p.WithServicesEnvironmentResolvedCtx(context.Background(), false, nil)
+	if err != nil {
+		t.Fatalf("WithServicesEnvironmentResolvedCtx: %v", err)
+	}
+	v := resolved.Services["web"].Environment["KEY"]
+	if v == nil || *v != "FROMFILE" {
+		t.Fatalf("got environment %v, want KEY=FROMFILE", resolved.Services["web"].Environment)
+	}
+}
+
+func TestWithServicesEnvironmentResolvedCtxMissingRequiredFile(t *testing.T) {
+	p := &Project{
+		Services: Services{
+			"web": ServiceConfig{
+				Name:     "web",
+				EnvFiles: []EnvFile{{Path: "/no/such/file.env", Required: true, Format: "json"}},
+			},
+		},
+	}
+	_, err := p.WithServicesEnvironmentResolvedCtx(context.Background(), false, nil)
+	if err == nil {
+		t.Fatal("expected an error for a missing required env file")
+	}
+	if !strings.Contains(err.Error(), "not found") {
+		t.Fatalf("expected a not-found error, got %v", err)
+	}
+}
+
+func TestWithServicesEnvironmentResolvedCtxMissingOptionalFileSkipped(t *testing.T) {
+	p := &Project{
+		Services: Services{
+			"web": ServiceConfig{
+				Name:     "web",
+				EnvFiles: []EnvFile{{Path: "/no/such/file.env", Required: false, Format: "json"}},
+			},
+		},
+	}
+	if _, err := p.WithServicesEnvironmentResolvedCtx(context.Background(), false, nil); err != nil {
+		t.Fatalf("expected a missing, non-required env file to be skipped, got %v", err)
+	}
+}
+
+// failingEnvProvider always fails with a non-not-found error, e.g. an auth
+// failure talking to a secrets backend.
+type failingEnvProvider struct{}
+
+func (failingEnvProvider) Load(context.Context, string) (io.ReadCloser, error) {
+	return nil, errors.New("permission denied")
+}
+
+func TestWithServicesEnvironmentResolvedCtxLoadFailureIsNotMislabeledNotFound(t *testing.T) {
+	p := &Project{
+		Services: Services{
+			"web": ServiceConfig{
+				Name:     "web",
+				EnvFiles: []EnvFile{{Path: "vault://secret/app", Required: true, Format: "json"}},
+			},
+		},
+	}
+	_, err := p.WithServicesEnvironmentResolvedCtx(context.Background(), false, map[string]EnvProvider{"vault": failingEnvProvider{}})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if strings.Contains(err.Error(), "not found") {
+		t.Fatalf("a non-not-found load failure must not be mislabeled as \"not found\", got %v", err)
+	}
+	if !strings.Contains(err.Error(), "failed to load") {
+		t.Fatalf("expected a \"failed to load\" error, got %v", err)
+	}
+}
@@ -0,0 +1,298 @@
+/*
+   Copyright 2020 The Compose Specification Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package types
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// Graph is a DAG of a Project's services. Edges come from DependsOn plus
+// the implicit dependencies this package derives from network_mode: service:x,
+// volumes_from, ipc and pid — a service sharing another service's network,
+// volumes, IPC or PID namespace needs that peer up first even though
+// DependsOn doesn't say so. extends is resolved away by the time a service
+// reaches ServiceConfig, so it never needs a graph edge of its own.
+type Graph struct {
+	nodes map[string]*ServiceConfig
+	// edges maps a service name to the set of service names it depends on.
+	edges map[string]map[string]struct{}
+}
+
+// DependencyGraph builds the DAG of services for this Project, with edges
+// derived from ServiceConfig.DependsOn plus the implicit peer dependencies
+// of network_mode, volumes_from, ipc and pid.
+func (p *Project) DependencyGraph() (*Graph, error) {
+	g := &Graph{
+		nodes: make(map[string]*ServiceConfig, len(p.Services)),
+		edges: make(map[string]map[string]struct{}, len(p.Services)),
+	}
+	for name, service := range p.Services {
+		s := service
+		g.nodes[name] = &s
+		deps := make(map[string]struct{}, len(service.DependsOn))
+		for dep := range service.DependsOn {
+			if _, ok := p.Services[dep]; !ok {
+				return nil, fmt.Errorf("service %q depends on undefined service %q", name, dep)
+			}
+			deps[dep] = struct{}{}
+		}
+		for _, dep := range implicitServiceDependencies(service) {
+			if _, ok := p.Services[dep]; !ok {
+				return nil, fmt.Errorf("service %q references undefined service %q", name, dep)
+			}
+			deps[dep] = struct{}{}
+		}
+		g.edges[name] = deps
+	}
+	if cycles := g.Cycles(); len(cycles) > 0 {
+		return nil, fmt.Errorf("dependency cycle detected: %s", formatCycles(cycles))
+	}
+	return g, nil
+}
+
+func formatCycles(cycles [][]string) string {
+	out := ""
+	for i, cycle := range cycles {
+		if i > 0 {
+			out += ", "
+		}
+		for j, name := range cycle {
+			if j > 0 {
+				out += "->"
+			}
+			out += name
+		}
+	}
+	return out
+}
+
+// Cycles returns every cycle found in the graph as the ordered list of
+// service names forming it, for use in diagnostic errors. An empty result
+// means the graph is a valid DAG.
+func (g *Graph) Cycles() [][]string {
+	const (
+		white = iota
+		gray
+		black
+	)
+	color := make(map[string]int, len(g.nodes))
+	var cycles [][]string
+	var stack []string
+
+	var visit func(name string)
+	visit = func(name string) {
+		color[name] = gray
+		stack = append(stack, name)
+		deps := sortedKeys(g.edges[name])
+		for _, dep := range deps {
+			switch color[dep] {
+			case white:
+				visit(dep)
+			case gray:
+				cycle := append([]string{}, stack...)
+				for len(cycle) > 0 && cycle[0] != dep {
+					cycle = cycle[1:]
+				}
+				cycles = append(cycles, append(cycle, dep))
+			}
+		}
+		stack = stack[:len(stack)-1]
+		color[name] = black
+	}
+
+	for _, name := range g.sortedNodes() {
+		if color[name] == white {
+			visit(name)
+		}
+	}
+	return cycles
+}
+
+// TopologicalOrder returns service names ordered so that a service always
+// appears after all the services it depends on.
+func (g *Graph) TopologicalOrder() ([]string, error) {
+	inDegree := make(map[string]int, len(g.nodes))
+	dependents := make(map[string][]string, len(g.nodes))
+	for name, deps := range g.edges {
+		inDegree[name] = len(deps)
+		for dep := range deps {
+			dependents[dep] = append(dependents[dep], name)
+		}
+	}
+
+	var ready []string
+	for _, name := range g.sortedNodes() {
+		if inDegree[name] == 0 {
+			ready = append(ready, name)
+		}
+	}
+	sort.Strings(ready)
+
+	order := make([]string, 0, len(g.nodes))
+	for len(ready) > 0 {
+		name := ready[0]
+		ready = ready[1:]
+		order = append(order, name)
+
+		next := append([]string{}, dependents[name]...)
+		sort.Strings(next)
+		for _, dependent := range next {
+			inDegree[dependent]--
+			if inDegree[dependent] == 0 {
+				ready = append(ready, dependent)
+				sort.Strings(ready)
+			}
+		}
+	}
+
+	if len(order) != len(g.nodes) {
+		return nil, fmt.Errorf("dependency cycle detected: %s", formatCycles(g.Cycles()))
+	}
+	return order, nil
+}
+
+// ReverseOrder returns service names ordered so that a service always
+// appears after all the services that depend on it, suitable for shutdown.
+func (g *Graph) ReverseOrder() ([]string, error) {
+	order, err := g.TopologicalOrder()
+	if err != nil {
+		return nil, err
+	}
+	reversed := make([]string, len(order))
+	for i, name := range order {
+		reversed[len(order)-1-i] = name
+	}
+	return reversed, nil
+}
+
+// RunParallel walks the graph running fn for each service once all its
+// dependencies have completed, using a worker pool bounded by
+// maxConcurrency. The first error returned by fn cancels ctx and stops
+// scheduling of remaining work.
+func (g *Graph) RunParallel(ctx context.Context, maxConcurrency int, fn ServiceFunc) error {
+	if maxConcurrency <= 0 {
+		maxConcurrency = len(g.nodes)
+	}
+	if _, err := g.TopologicalOrder(); err != nil {
+		return err
+	}
+
+	eg, ctx := errgroup.WithContext(ctx)
+	sem := make(chan struct{}, maxConcurrency)
+
+	var mu sync.Mutex
+	done := make(map[string]chan struct{}, len(g.nodes))
+	for name := range g.nodes {
+		done[name] = make(chan struct{})
+	}
+
+	for name, service := range g.nodes {
+		name, service := name, service
+		deps := sortedKeys(g.edges[name])
+		eg.Go(func() error {
+			for _, dep := range deps {
+				select {
+				case <-done[dep]:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			defer func() { <-sem }()
+
+			mu.Lock()
+			s := *service
+			mu.Unlock()
+			if err := fn(name, &s); err != nil {
+				return err
+			}
+			close(done[name])
+			return nil
+		})
+	}
+	return eg.Wait()
+}
+
+func (g *Graph) sortedNodes() []string {
+	names := make([]string, 0, len(g.nodes))
+	for name := range g.nodes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func sortedKeys(m map[string]struct{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// implicitServiceDependencies returns the service names s shares a
+// namespace with via network_mode, volumes_from, ipc or pid, none of
+// which are reflected in DependsOn. Entries naming an external container
+// ("container:x") rather than a service are skipped: they aren't nodes in
+// this Project's graph.
+func implicitServiceDependencies(s ServiceConfig) []string {
+	var deps []string
+	if dep, ok := serviceRef(s.NetworkMode); ok {
+		deps = append(deps, dep)
+	}
+	if dep, ok := serviceRef(s.Ipc); ok {
+		deps = append(deps, dep)
+	}
+	if dep, ok := serviceRef(s.Pid); ok {
+		deps = append(deps, dep)
+	}
+	for _, v := range s.VolumesFrom {
+		if strings.HasPrefix(v, "container:") {
+			continue
+		}
+		// volumes_from names the service directly, optionally followed by
+		// a ":ro"/":rw" mode that isn't part of the name.
+		if dep, _, _ := strings.Cut(v, ":"); dep != "" {
+			deps = append(deps, dep)
+		}
+	}
+	return deps
+}
+
+// serviceRef extracts the service name from a "service:<name>" reference,
+// as used by network_mode, ipc and pid. Anything after a further ":" isn't
+// part of the name and is dropped.
+func serviceRef(ref string) (string, bool) {
+	name, ok := strings.CutPrefix(ref, "service:")
+	if !ok {
+		return "", false
+	}
+	name, _, _ = strings.Cut(name, ":")
+	return name, name != ""
+}
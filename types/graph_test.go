@@ -0,0 +1,210 @@
+/*
+   Copyright 2020 The Compose Specification Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package types
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+)
+
+func TestDependencyGraphImplicitEdges(t *testing.T) {
+	p := &Project{
+		Services: Services{
+			"db":  ServiceConfig{Name: "db"},
+			"web": ServiceConfig{Name: "web", NetworkMode: "service:db"},
+			"cli": ServiceConfig{Name: "cli", VolumesFrom: []string{"web:ro"}},
+		},
+	}
+
+	g, err := p.DependencyGraph()
+	if err != nil {
+		t.Fatalf("DependencyGraph: %v", err)
+	}
+
+	order, err := g.TopologicalOrder()
+	if err != nil {
+		t.Fatalf("TopologicalOrder: %v", err)
+	}
+	index := make(map[string]int, len(order))
+	for i, name := range order {
+		index[name] = i
+	}
+	if index["db"] >= index["web"] {
+		t.Errorf("expected db before web (network_mode), got order %v", order)
+	}
+	if index["web"] >= index["cli"] {
+		t.Errorf("expected web before cli (volumes_from), got order %v", order)
+	}
+}
+
+func TestDependencyGraphUndefinedImplicitPeer(t *testing.T) {
+	p := &Project{
+		Services: Services{
+			"web": ServiceConfig{Name: "web", Ipc: "service:ghost"},
+		},
+	}
+	if _, err := p.DependencyGraph(); err == nil {
+		t.Fatal("expected an error for an ipc peer that doesn't exist")
+	}
+}
+
+func TestDependencyGraphDetectsCycle(t *testing.T) {
+	p := &Project{
+		Services: Services{
+			"a": ServiceConfig{Name: "a", DependsOn: map[string]ServiceDependency{"b": {}}},
+			"b": ServiceConfig{Name: "b", DependsOn: map[string]ServiceDependency{"a": {}}},
+		},
+	}
+	if _, err := p.DependencyGraph(); err == nil {
+		t.Fatal("expected a cycle error")
+	}
+}
+
+func TestGraphTopologicalOrder(t *testing.T) {
+	p := &Project{
+		Services: Services{
+			"a": ServiceConfig{Name: "a"},
+			"b": ServiceConfig{Name: "b", DependsOn: map[string]ServiceDependency{"a": {}}},
+			"c": ServiceConfig{Name: "c", DependsOn: map[string]ServiceDependency{"b": {}}},
+		},
+	}
+	g, err := p.DependencyGraph()
+	if err != nil {
+		t.Fatalf("DependencyGraph: %v", err)
+	}
+	order, err := g.TopologicalOrder()
+	if err != nil {
+		t.Fatalf("TopologicalOrder: %v", err)
+	}
+	if got, want := order, []string{"a", "b", "c"}; !exactStrings(got, want) {
+		t.Fatalf("got order %v, want %v", got, want)
+	}
+
+	reverse, err := g.ReverseOrder()
+	if err != nil {
+		t.Fatalf("ReverseOrder: %v", err)
+	}
+	if got, want := reverse, []string{"c", "b", "a"}; !exactStrings(got, want) {
+		t.Fatalf("got reverse order %v, want %v", got, want)
+	}
+}
+
+func TestGraphRunParallelRespectsDependencies(t *testing.T) {
+	p := &Project{
+		Services: Services{
+			"a": ServiceConfig{Name: "a"},
+			"b": ServiceConfig{Name: "b", DependsOn: map[string]ServiceDependency{"a": {}}},
+			"c": ServiceConfig{Name: "c", DependsOn: map[string]ServiceDependency{"a": {}}},
+			"d": ServiceConfig{Name: "d", DependsOn: map[string]ServiceDependency{"b": {}, "c": {}}},
+		},
+	}
+	g, err := p.DependencyGraph()
+	if err != nil {
+		t.Fatalf("DependencyGraph: %v", err)
+	}
+
+	var mu sync.Mutex
+	var started []string
+	err = g.RunParallel(context.Background(), 2, func(name string, _ *ServiceConfig) error {
+		mu.Lock()
+		started = append(started, name)
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("RunParallel: %v", err)
+	}
+
+	index := make(map[string]int, len(started))
+	for i, name := range started {
+		index[name] = i
+	}
+	if index["a"] >= index["b"] || index["a"] >= index["c"] {
+		t.Errorf("a must run before b and c, got order %v", started)
+	}
+	if index["b"] >= index["d"] || index["c"] >= index["d"] {
+		t.Errorf("b and c must run before d, got order %v", started)
+	}
+}
+
+func TestGraphRunParallelPropagatesError(t *testing.T) {
+	p := &Project{
+		Services: Services{
+			"a": ServiceConfig{Name: "a"},
+			"b": ServiceConfig{Name: "b", DependsOn: map[string]ServiceDependency{"a": {}}},
+		},
+	}
+	g, err := p.DependencyGraph()
+	if err != nil {
+		t.Fatalf("DependencyGraph: %v", err)
+	}
+
+	boom := context.Canceled
+	err = g.RunParallel(context.Background(), 2, func(name string, _ *ServiceConfig) error {
+		if name == "a" {
+			return boom
+		}
+		return nil
+	})
+	if err != boom {
+		t.Fatalf("got error %v, want %v", err, boom)
+	}
+}
+
+func TestServiceRef(t *testing.T) {
+	cases := []struct {
+		ref     string
+		name    string
+		matched bool
+	}{
+		{"service:db", "db", true},
+		{"service:db:ro", "db", true},
+		{"container:db", "", false},
+		{"", "", false},
+		{"service:", "", false},
+	}
+	for _, tc := range cases {
+		name, ok := serviceRef(tc.ref)
+		if ok != tc.matched || name != tc.name {
+			t.Errorf("serviceRef(%q) = (%q, %v), want (%q, %v)", tc.ref, name, ok, tc.name, tc.matched)
+		}
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a, b = append([]string{}, a...), append([]string{}, b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	return exactStrings(a, b)
+}
+
+func exactStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
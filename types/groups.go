@@ -0,0 +1,202 @@
+/*
+   Copyright 2020 The Compose Specification Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package types
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/compose-spec/compose-go/v2/utils"
+)
+
+// groupsExtensionKey is the top-level extension Project.Groups is parsed
+// from when the loader hasn't already populated it explicitly.
+const groupsExtensionKey = "x-groups"
+
+// WithGroupsFromExtensions populates Project.Groups by parsing the
+// x-groups top-level extension (group name -> list of service and/or
+// group names), so a project loaded straight from YAML works with
+// WithSelectedGroups/ResolveTargets without the caller building the map
+// by hand. A Project whose Groups was already set (e.g. by a caller that
+// built it directly) is returned unchanged.
+// It returns a new Project instance with the changes and keeps the
+// original Project unchanged.
+func (p *Project) WithGroupsFromExtensions() (*Project, error) {
+	if p.Groups != nil {
+		return p, nil
+	}
+	newProject := p.deepCopy()
+
+	raw, ok := newProject.Extensions[groupsExtensionKey]
+	if !ok {
+		return newProject, nil
+	}
+	m, ok := raw.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%s: expected a mapping, got %T", groupsExtensionKey, raw)
+	}
+
+	groups := make(map[string][]string, len(m))
+	for name, members := range m {
+		list, ok := members.([]any)
+		if !ok {
+			return nil, fmt.Errorf("%s.%s: expected a list, got %T", groupsExtensionKey, name, members)
+		}
+		names := make([]string, 0, len(list))
+		for _, member := range list {
+			s, ok := member.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s.%s: expected a list of strings, got %T", groupsExtensionKey, name, member)
+			}
+			names = append(names, s)
+		}
+		groups[name] = names
+	}
+	newProject.Groups = groups
+	return newProject, nil
+}
+
+// GroupNames return names for all groups declared by `x-groups` in this
+// Compose config
+func (p *Project) GroupNames() []string {
+	names := make([]string, 0, len(p.Groups))
+	for k := range p.Groups {
+		names = append(names, k)
+	}
+	return names
+}
+
+// resolveGroup flattens a group name into the set of service names it
+// refers to, recursively expanding any group it references. seen holds the
+// groups on the current expansion path and guards against a group cycle.
+func (p *Project) resolveGroup(name string, seen map[string]bool) ([]string, error) {
+	if seen[name] {
+		return nil, fmt.Errorf("group %q is part of a cycle", name)
+	}
+	seen[name] = true
+	defer delete(seen, name)
+
+	members, ok := p.Groups[name]
+	if !ok {
+		return nil, fmt.Errorf("no such group: %s", name)
+	}
+
+	var services []string
+	for _, member := range members {
+		if _, ok := p.Groups[member]; ok {
+			nested, err := p.resolveGroup(member, seen)
+			if err != nil {
+				return nil, err
+			}
+			services = append(services, nested...)
+			continue
+		}
+		services = append(services, member)
+	}
+	return services, nil
+}
+
+// WithSelectedGroups restricts the project model to the services named by
+// the given groups (and their dependencies), resolving group names
+// recursively as groups may reference other groups or services directly.
+// It returns a new Project instance with the changes and keeps the original
+// Project unchanged.
+func (p *Project) WithSelectedGroups(names []string, options ...DependencyOption) (*Project, error) {
+	p, err := p.WithGroupsFromExtensions()
+	if err != nil {
+		return nil, err
+	}
+
+	set := utils.NewSet[string]()
+	for _, name := range names {
+		services, err := p.resolveGroup(name, map[string]bool{})
+		if err != nil {
+			return nil, err
+		}
+		for _, service := range services {
+			set.Add(service)
+		}
+	}
+	return p.WithSelectedServices(utils.MapKeys(set), options...)
+}
+
+// ResolveTargets expands patterns into a flat, deduplicated list of service
+// names. Patterns are matched against both service and group names (groups
+// are expanded to their member services), support glob matching
+// (`web-*`) and negation (`!db` removes a previously matched name).
+func (p *Project) ResolveTargets(patterns []string) ([]string, error) {
+	p, err := p.WithGroupsFromExtensions()
+	if err != nil {
+		return nil, err
+	}
+
+	selected := utils.NewSet[string]()
+
+	candidates := append([]string{}, p.ServiceNames()...)
+	for _, group := range p.GroupNames() {
+		candidates = append(candidates, group)
+	}
+
+	for _, pattern := range patterns {
+		negate := strings.HasPrefix(pattern, "!")
+		glob := strings.TrimPrefix(pattern, "!")
+
+		var matched []string
+		for _, candidate := range candidates {
+			ok, err := filepath.Match(glob, candidate)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+			}
+			if ok {
+				matched = append(matched, candidate)
+			}
+		}
+
+		names, err := p.expandTargets(matched)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			if negate {
+				delete(selected, name)
+			} else {
+				selected.Add(name)
+			}
+		}
+	}
+
+	return utils.MapKeys(selected), nil
+}
+
+// expandTargets resolves a mix of service and group names into service
+// names only.
+func (p *Project) expandTargets(names []string) ([]string, error) {
+	var services []string
+	for _, name := range names {
+		if _, ok := p.Groups[name]; ok {
+			members, err := p.resolveGroup(name, map[string]bool{})
+			if err != nil {
+				return nil, err
+			}
+			services = append(services, members...)
+			continue
+		}
+		services = append(services, name)
+	}
+	return services, nil
+}
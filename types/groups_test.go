@@ -0,0 +1,151 @@
+/*
+   Copyright 2020 The Compose Specification Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package types
+
+import (
+	"sort"
+	"testing"
+)
+
+func groupsFixture() *Project {
+	return &Project{
+		Services: Services{
+			"web":    ServiceConfig{Name: "web"},
+			"worker": ServiceConfig{Name: "worker"},
+			"db":     ServiceConfig{Name: "db"},
+		},
+		Groups: map[string][]string{
+			"backend":  {"worker", "db"},
+			"frontend": {"web"},
+			"all":      {"frontend", "backend"},
+		},
+	}
+}
+
+func TestResolveGroupFlattensNestedGroups(t *testing.T) {
+	p := groupsFixture()
+
+	services, err := p.resolveGroup("all", map[string]bool{})
+	if err != nil {
+		t.Fatalf("resolveGroup: %v", err)
+	}
+	sort.Strings(services)
+	if got, want := services, []string{"db", "web", "worker"}; !exactStrings(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestResolveGroupDetectsCycle(t *testing.T) {
+	p := &Project{
+		Groups: map[string][]string{
+			"a": {"b"},
+			"b": {"a"},
+		},
+	}
+	if _, err := p.resolveGroup("a", map[string]bool{}); err == nil {
+		t.Fatal("expected a cycle error")
+	}
+}
+
+func TestResolveGroupUnknownGroup(t *testing.T) {
+	p := groupsFixture()
+	if _, err := p.resolveGroup("ghost", map[string]bool{}); err == nil {
+		t.Fatal("expected an error for an undeclared group")
+	}
+}
+
+func TestWithGroupsFromExtensionsParsesXGroups(t *testing.T) {
+	p := &Project{
+		Services: Services{
+			"web": ServiceConfig{Name: "web"},
+			"db":  ServiceConfig{Name: "db"},
+		},
+		Extensions: Extensions{
+			"x-groups": map[string]any{
+				"backend": []any{"db"},
+			},
+		},
+	}
+
+	resolved, err := p.WithGroupsFromExtensions()
+	if err != nil {
+		t.Fatalf("WithGroupsFromExtensions: %v", err)
+	}
+	if got, want := resolved.Groups["backend"], []string{"db"}; !exactStrings(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if p.Groups != nil {
+		t.Fatal("WithGroupsFromExtensions must not mutate the original project")
+	}
+}
+
+func TestWithGroupsFromExtensionsNoExtension(t *testing.T) {
+	p := &Project{Services: Services{"web": ServiceConfig{Name: "web"}}}
+
+	resolved, err := p.WithGroupsFromExtensions()
+	if err != nil {
+		t.Fatalf("WithGroupsFromExtensions: %v", err)
+	}
+	if len(resolved.Groups) != 0 {
+		t.Fatalf("expected no groups, got %v", resolved.Groups)
+	}
+}
+
+func TestWithGroupsFromExtensionsInvalidShape(t *testing.T) {
+	p := &Project{
+		Extensions: Extensions{
+			"x-groups": map[string]any{
+				"backend": "not-a-list",
+			},
+		},
+	}
+	if _, err := p.WithGroupsFromExtensions(); err == nil {
+		t.Fatal("expected an error for a non-list group member set")
+	}
+}
+
+func TestResolveTargetsGlobAndNegation(t *testing.T) {
+	p := &Project{
+		Services: Services{
+			"web-1": ServiceConfig{Name: "web-1"},
+			"web-2": ServiceConfig{Name: "web-2"},
+			"db":    ServiceConfig{Name: "db"},
+		},
+	}
+
+	names, err := p.ResolveTargets([]string{"web-*", "!web-2"})
+	if err != nil {
+		t.Fatalf("ResolveTargets: %v", err)
+	}
+	sort.Strings(names)
+	if got, want := names, []string{"web-1"}; !exactStrings(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestResolveTargetsExpandsGroups(t *testing.T) {
+	p := groupsFixture()
+
+	names, err := p.ResolveTargets([]string{"backend"})
+	if err != nil {
+		t.Fatalf("ResolveTargets: %v", err)
+	}
+	sort.Strings(names)
+	if got, want := names, []string{"db", "worker"}; !exactStrings(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,110 @@
+/*
+   Copyright 2020 The Compose Specification Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package types
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+)
+
+// WithConsistentHashes computes a stable content hash for each service and
+// stores it in ServiceConfig.Hash. The hash is derived from the service's
+// canonical JSON representation, so it is deterministic across Go versions
+// and independent of map iteration order, but it deliberately excludes
+// fields that only reflect how the project was loaded rather than what it
+// declares (WorkingDir, ComposeFiles, Environment, IncludeReferences).
+// It returns a new Project instance with the changes and keeps the original
+// Project unchanged.
+func (p *Project) WithConsistentHashes() (*Project, error) {
+	newProject := p.deepCopy()
+
+	for name, service := range newProject.Services {
+		hash, err := canonicalHash(service)
+		if err != nil {
+			return nil, err
+		}
+		service.Hash = hash
+		newProject.Services[name] = service
+	}
+
+	return newProject, nil
+}
+
+// Hash returns a single digest combining the canonical hash of every
+// service, network, volume, secret and config in the project, so callers
+// such as a diff-based deploy tool can cheaply detect that nothing changed
+// at all since the last apply.
+func (p *Project) Hash() (string, error) {
+	hashed, err := p.WithConsistentHashes()
+	if err != nil {
+		return "", err
+	}
+
+	var digests []string
+	for name, service := range hashed.Services {
+		digests = append(digests, "service:"+name+":"+service.Hash)
+	}
+	for name, network := range hashed.Networks {
+		hash, err := canonicalHash(network)
+		if err != nil {
+			return "", err
+		}
+		digests = append(digests, "network:"+name+":"+hash)
+	}
+	for name, volume := range hashed.Volumes {
+		hash, err := canonicalHash(volume)
+		if err != nil {
+			return "", err
+		}
+		digests = append(digests, "volume:"+name+":"+hash)
+	}
+	for name, secret := range hashed.Secrets {
+		hash, err := canonicalHash(secret)
+		if err != nil {
+			return "", err
+		}
+		digests = append(digests, "secret:"+name+":"+hash)
+	}
+	for name, config := range hashed.Configs {
+		hash, err := canonicalHash(config)
+		if err != nil {
+			return "", err
+		}
+		digests = append(digests, "config:"+name+":"+hash)
+	}
+	sort.Strings(digests)
+
+	h := sha256.New()
+	for _, d := range digests {
+		h.Write([]byte(d))
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// canonicalHash marshals v as JSON with object keys sorted (the default for
+// encoding/json, unlike gopkg.in/yaml.v3 which gives no ordering guarantee)
+// and returns its sha256 digest, hex encoded.
+func canonicalHash(v any) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,132 @@
+/*
+   Copyright 2020 The Compose Specification Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package types
+
+import "testing"
+
+func TestCanonicalHashStableAcrossMapOrder(t *testing.T) {
+	a := ServiceConfig{
+		Name:  "web",
+		Image: "nginx",
+		Environment: MappingWithEquals{
+			"A": strPtr("1"),
+			"B": strPtr("2"),
+		},
+	}
+	b := ServiceConfig{
+		Name:  "web",
+		Image: "nginx",
+		Environment: MappingWithEquals{
+			"B": strPtr("2"),
+			"A": strPtr("1"),
+		},
+	}
+
+	ha, err := canonicalHash(a)
+	if err != nil {
+		t.Fatalf("canonicalHash(a): %v", err)
+	}
+	hb, err := canonicalHash(b)
+	if err != nil {
+		t.Fatalf("canonicalHash(b): %v", err)
+	}
+	if ha != hb {
+		t.Fatalf("expected identical hashes regardless of map iteration order, got %q and %q", ha, hb)
+	}
+}
+
+func TestCanonicalHashChangesWithContent(t *testing.T) {
+	a := ServiceConfig{Name: "web", Image: "nginx:1.25"}
+	b := ServiceConfig{Name: "web", Image: "nginx:1.26"}
+
+	ha, err := canonicalHash(a)
+	if err != nil {
+		t.Fatalf("canonicalHash(a): %v", err)
+	}
+	hb, err := canonicalHash(b)
+	if err != nil {
+		t.Fatalf("canonicalHash(b): %v", err)
+	}
+	if ha == hb {
+		t.Fatal("expected different hashes for different image tags")
+	}
+}
+
+func TestWithConsistentHashesSetsPerServiceHash(t *testing.T) {
+	p := &Project{
+		Services: Services{
+			"web": ServiceConfig{Name: "web", Image: "nginx"},
+			"db":  ServiceConfig{Name: "db", Image: "postgres"},
+		},
+	}
+
+	hashed, err := p.WithConsistentHashes()
+	if err != nil {
+		t.Fatalf("WithConsistentHashes: %v", err)
+	}
+	for name, service := range hashed.Services {
+		if service.Hash == "" {
+			t.Errorf("service %s: expected a non-empty Hash", name)
+		}
+	}
+	if p.Services["web"].Hash != "" {
+		t.Fatal("WithConsistentHashes must not mutate the original project")
+	}
+}
+
+func TestProjectHashDeterministicAndSensitiveToChange(t *testing.T) {
+	base := &Project{
+		Services: Services{
+			"web": ServiceConfig{Name: "web", Image: "nginx"},
+		},
+		Networks: Networks{
+			"default": NetworkConfig{Driver: "bridge"},
+		},
+	}
+
+	h1, err := base.Hash()
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+	h2, err := base.Hash()
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+	if h1 != h2 {
+		t.Fatalf("expected Hash to be deterministic, got %q then %q", h1, h2)
+	}
+
+	changed := &Project{
+		Services: Services{
+			"web": ServiceConfig{Name: "web", Image: "nginx:alpine"},
+		},
+		Networks: Networks{
+			"default": NetworkConfig{Driver: "bridge"},
+		},
+	}
+	h3, err := changed.Hash()
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+	if h1 == h3 {
+		t.Fatal("expected Hash to change when a service's image changes")
+	}
+}
+
+func strPtr(s string) *string {
+	return &s
+}
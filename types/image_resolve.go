@@ -0,0 +1,249 @@
+/*
+   Copyright 2020 The Compose Specification Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package types
+
+import (
+	"context"
+	"sync"
+
+	"github.com/distribution/reference"
+	godigest "github.com/opencontainers/go-digest"
+	"golang.org/x/sync/errgroup"
+)
+
+// ImageResolver resolves a named image reference to the digest (and raw
+// manifest) a registry currently serves for it. platform selects which
+// entry of a multi-arch manifest list to resolve ("linux/amd64", ...); an
+// empty platform means the resolver's default/native platform. Implementations
+// are expected to handle their own auth and retries.
+type ImageResolver interface {
+	Resolve(ctx context.Context, ref reference.Named, platform string) (godigest.Digest, []byte, error)
+}
+
+// ManifestCache lets callers dedup resolution of the same image reference
+// across services, e.g. when several services share a base image. Entries
+// are keyed per platform, since different platforms resolve to different
+// digests for the same reference.
+type ManifestCache interface {
+	Get(ref, platform string) (godigest.Digest, []byte, bool)
+	Set(ref, platform string, digest godigest.Digest, manifest []byte)
+}
+
+// ResolveMode controls whether WithImagesResolvedCtx contacts the registry
+// for images which already carry a digest.
+type ResolveMode int
+
+const (
+	// ResolveAlways always resolves, even for images already pinned by digest.
+	ResolveAlways ResolveMode = iota
+	// ResolvePreferLocal skips resolution for images already pinned by digest.
+	ResolvePreferLocal
+	// ResolveNever never contacts the registry; only images already pinned
+	// by digest are considered resolved.
+	ResolveNever
+)
+
+type resolveOptions struct {
+	concurrency int
+	cache       ManifestCache
+	platforms   []string
+	mode        ResolveMode
+}
+
+// ResolveOption customizes WithImagesResolvedCtx.
+type ResolveOption func(*resolveOptions)
+
+// WithResolveConcurrency bounds how many images are resolved at once.
+// Defaults to resolving every service's image concurrently.
+func WithResolveConcurrency(n int) ResolveOption {
+	return func(o *resolveOptions) {
+		o.concurrency = n
+	}
+}
+
+// WithManifestCache dedups resolution of identical image references across
+// services.
+func WithManifestCache(cache ManifestCache) ResolveOption {
+	return func(o *resolveOptions) {
+		o.cache = cache
+	}
+}
+
+// WithPlatform requests digests be resolved for each of the given
+// platforms (e.g. "linux/amd64", "linux/arm64"), instead of the resolver's
+// default platform.
+func WithPlatform(platforms ...string) ResolveOption {
+	return func(o *resolveOptions) {
+		o.platforms = platforms
+	}
+}
+
+// WithResolveMode sets whether already-pinned images are re-resolved.
+func WithResolveMode(mode ResolveMode) ResolveOption {
+	return func(o *resolveOptions) {
+		o.mode = mode
+	}
+}
+
+// ResolvedImage is the outcome of resolving a service's image for a single
+// platform.
+type ResolvedImage struct {
+	Platform string          `yaml:"-" json:"-"`
+	Digest   godigest.Digest `yaml:"-" json:"-"`
+	Manifest []byte          `yaml:"-" json:"-"`
+}
+
+// WithImagesResolvedCtx updates services images to include the digest
+// returned by r, and persists the resolved manifest(s) on
+// ServiceConfig.ResolvedImages so downstream tooling can inspect labels and
+// platforms without a second registry round-trip.
+//
+// Unlike WithImagesResolved, resolution is driven by an ImageResolver
+// (bring your own auth/retries), is bounded by WithResolveConcurrency,
+// can be deduped across services sharing an image with WithManifestCache,
+// and can resolve more than one platform's digest via WithPlatform.
+//
+// service.Image is only rewritten to a digest reference when exactly one
+// platform was resolved (the resolver's default, or the single platform
+// given to WithPlatform): that digest unambiguously identifies that
+// platform's manifest. When WithPlatform
+// requests more than one platform, there is no single manifest digest that
+// correctly represents all of them — a manifest-list/index digest would,
+// but ImageResolver only resolves per-platform manifests — so service.Image
+// is left untouched and callers must read the per-platform digests off
+// ServiceConfig.ResolvedImages instead.
+// It returns a new Project instance with the changes and keeps the original
+// Project unchanged.
+func (p *Project) WithImagesResolvedCtx(ctx context.Context, r ImageResolver, opts ...ResolveOption) (*Project, error) {
+	newProject := p.deepCopy()
+
+	options := resolveOptions{
+		concurrency: len(newProject.Services),
+		mode:        ResolveAlways,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.concurrency <= 0 {
+		options.concurrency = 1
+	}
+	platforms := options.platforms
+	if len(platforms) == 0 {
+		platforms = []string{""}
+	}
+
+	eg, ctx := errgroup.WithContext(ctx)
+	sem := make(chan struct{}, options.concurrency)
+
+	var mu sync.Mutex
+	resolvedServices := make(map[string]ServiceConfig, len(newProject.Services))
+	// inflight serializes cached resolution of the same reference and
+	// platform, so services sharing an image find the first result in the
+	// cache instead of all missing it at once.
+	var inflight sync.Map
+
+	for i, s := range newProject.Services {
+		idx := i
+		service := s
+		if service.Image == "" {
+			continue
+		}
+
+		eg.Go(func() error {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			defer func() { <-sem }()
+
+			named, err := reference.ParseDockerRef(service.Image)
+			if err != nil {
+				return err
+			}
+
+			_, pinned := named.(reference.Canonical)
+			if pinned && options.mode == ResolvePreferLocal {
+				return nil
+			}
+			if options.mode == ResolveNever {
+				return nil
+			}
+
+			resolved := make([]ResolvedImage, 0, len(platforms))
+			for _, platform := range platforms {
+				var lock *sync.Mutex
+				if options.cache != nil {
+					l, _ := inflight.LoadOrStore(named.String()+"@"+platform, &sync.Mutex{})
+					lock = l.(*sync.Mutex)
+					lock.Lock()
+				}
+				digest, manifest, err := resolveWithCache(ctx, r, named, platform, options.cache)
+				if lock != nil {
+					lock.Unlock()
+				}
+				if err != nil {
+					return err
+				}
+				resolved = append(resolved, ResolvedImage{
+					Platform: platform,
+					Digest:   digest,
+					Manifest: manifest,
+				})
+			}
+
+			if !pinned && len(resolved) == 1 {
+				named, err = reference.WithDigest(named, resolved[0].Digest)
+				if err != nil {
+					return err
+				}
+				service.Image = named.String()
+			}
+			service.ResolvedImages = resolved
+
+			mu.Lock()
+			resolvedServices[idx] = service
+			mu.Unlock()
+			return nil
+		})
+	}
+
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
+	for idx, service := range resolvedServices {
+		newProject.Services[idx] = service
+	}
+	return newProject, nil
+}
+
+func resolveWithCache(ctx context.Context, r ImageResolver, named reference.Named, platform string, cache ManifestCache) (godigest.Digest, []byte, error) {
+	key := named.String()
+	if cache != nil {
+		if digest, manifest, ok := cache.Get(key, platform); ok {
+			return digest, manifest, nil
+		}
+	}
+	digest, manifest, err := r.Resolve(ctx, named, platform)
+	if err != nil {
+		return "", nil, err
+	}
+	if cache != nil {
+		cache.Set(key, platform, digest, manifest)
+	}
+	return digest, manifest, nil
+}
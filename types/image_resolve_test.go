@@ -0,0 +1,208 @@
+/*
+   Copyright 2020 The Compose Specification Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package types
+
+import (
+	"context"
+	"crypto/sha256"
+	"sync"
+	"testing"
+
+	"github.com/distribution/reference"
+	godigest "github.com/opencontainers/go-digest"
+)
+
+var (
+	_ ImageResolver = (*fakeResolver)(nil)
+	_ ManifestCache = (*fakeManifestCache)(nil)
+)
+
+// fakeResolver resolves every reference to a deterministic digest derived
+// from the reference and platform, and counts how many times it was called
+// per (ref, platform) so tests can assert on dedup/concurrency behavior.
+type fakeResolver struct {
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+func newFakeResolver() *fakeResolver {
+	return &fakeResolver{calls: map[string]int{}}
+}
+
+func (r *fakeResolver) Resolve(_ context.Context, ref reference.Named, platform string) (godigest.Digest, []byte, error) {
+	key := ref.String() + "@" + platform
+	r.mu.Lock()
+	r.calls[key]++
+	r.mu.Unlock()
+
+	manifest := []byte(key)
+	sum := sha256.Sum256(manifest)
+	return godigest.NewDigestFromBytes(godigest.SHA256, sum[:]), manifest, nil
+}
+
+func (r *fakeResolver) callCount(ref, platform string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.calls[ref+"@"+platform]
+}
+
+type fakeManifestCache struct {
+	mu      sync.Mutex
+	entries map[string]ResolvedImage
+}
+
+func newFakeManifestCache() *fakeManifestCache {
+	return &fakeManifestCache{entries: map[string]ResolvedImage{}}
+}
+
+func (c *fakeManifestCache) Get(ref, platform string) (godigest.Digest, []byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[ref+"@"+platform]
+	if !ok {
+		return "", nil, false
+	}
+	return entry.Digest, entry.Manifest, true
+}
+
+func (c *fakeManifestCache) Set(ref, platform string, digest godigest.Digest, manifest []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[ref+"@"+platform] = ResolvedImage{Platform: platform, Digest: digest, Manifest: manifest}
+}
+
+func TestWithImagesResolvedCtxSinglePlatformPinsDigest(t *testing.T) {
+	p := &Project{
+		Services: Services{
+			"web": ServiceConfig{Name: "web", Image: "nginx"},
+		},
+	}
+
+	resolver := newFakeResolver()
+	resolved, err := p.WithImagesResolvedCtx(context.Background(), resolver)
+	if err != nil {
+		t.Fatalf("WithImagesResolvedCtx: %v", err)
+	}
+
+	service := resolved.Services["web"]
+	if service.Image == "nginx" {
+		t.Fatal("expected service.Image to be rewritten with a digest")
+	}
+	if len(service.ResolvedImages) != 1 {
+		t.Fatalf("expected exactly one ResolvedImages entry, got %d", len(service.ResolvedImages))
+	}
+	if p.Services["web"].Image != "nginx" {
+		t.Fatal("WithImagesResolvedCtx must not mutate the original project")
+	}
+}
+
+func TestWithImagesResolvedCtxMultiPlatformLeavesImageUntouched(t *testing.T) {
+	p := &Project{
+		Services: Services{
+			"web": ServiceConfig{Name: "web", Image: "nginx"},
+		},
+	}
+
+	resolver := newFakeResolver()
+	resolved, err := p.WithImagesResolvedCtx(context.Background(), resolver, WithPlatform("linux/amd64", "linux/arm64"))
+	if err != nil {
+		t.Fatalf("WithImagesResolvedCtx: %v", err)
+	}
+
+	service := resolved.Services["web"]
+	if service.Image != "nginx" {
+		t.Fatalf("expected service.Image to be left untouched for multiple platforms, got %q", service.Image)
+	}
+	if len(service.ResolvedImages) != 2 {
+		t.Fatalf("expected two ResolvedImages entries, got %d", len(service.ResolvedImages))
+	}
+	platforms := map[string]bool{}
+	for _, ri := range service.ResolvedImages {
+		platforms[ri.Platform] = true
+	}
+	if !platforms["linux/amd64"] || !platforms["linux/arm64"] {
+		t.Fatalf("expected both platforms resolved, got %v", service.ResolvedImages)
+	}
+}
+
+func TestWithImagesResolvedCtxResolveNeverSkipsResolution(t *testing.T) {
+	p := &Project{
+		Services: Services{
+			"web": ServiceConfig{Name: "web", Image: "nginx"},
+		},
+	}
+
+	resolver := newFakeResolver()
+	resolved, err := p.WithImagesResolvedCtx(context.Background(), resolver, WithResolveMode(ResolveNever))
+	if err != nil {
+		t.Fatalf("WithImagesResolvedCtx: %v", err)
+	}
+	if resolved.Services["web"].Image != "nginx" {
+		t.Fatal("ResolveNever must leave the image untouched")
+	}
+	if len(resolved.Services["web"].ResolvedImages) != 0 {
+		t.Fatal("ResolveNever must not populate ResolvedImages")
+	}
+}
+
+func TestWithImagesResolvedCtxUsesManifestCache(t *testing.T) {
+	p := &Project{
+		Services: Services{
+			"web":   ServiceConfig{Name: "web", Image: "nginx"},
+			"web-2": ServiceConfig{Name: "web-2", Image: "nginx"},
+		},
+	}
+
+	resolver := newFakeResolver()
+	cache := newFakeManifestCache()
+	_, err := p.WithImagesResolvedCtx(context.Background(), resolver, WithManifestCache(cache))
+	if err != nil {
+		t.Fatalf("WithImagesResolvedCtx: %v", err)
+	}
+
+	named, err := reference.ParseDockerRef("nginx")
+	if err != nil {
+		t.Fatalf("ParseDockerRef: %v", err)
+	}
+	if got := resolver.callCount(named.String(), ""); got != 1 {
+		t.Fatalf("expected the resolver to be called once for a cached, shared image, got %d calls", got)
+	}
+}
+
+func TestResolveWithCachePopulatesCacheOnMiss(t *testing.T) {
+	resolver := newFakeResolver()
+	cache := newFakeManifestCache()
+	named, err := reference.ParseDockerRef("alpine")
+	if err != nil {
+		t.Fatalf("ParseDockerRef: %v", err)
+	}
+
+	digest1, _, err := resolveWithCache(context.Background(), resolver, named, "linux/amd64", cache)
+	if err != nil {
+		t.Fatalf("resolveWithCache: %v", err)
+	}
+	digest2, _, err := resolveWithCache(context.Background(), resolver, named, "linux/amd64", cache)
+	if err != nil {
+		t.Fatalf("resolveWithCache: %v", err)
+	}
+	if digest1 != digest2 {
+		t.Fatalf("expected the same digest from cache, got %q and %q", digest1, digest2)
+	}
+	if got := resolver.callCount(named.String(), "linux/amd64"); got != 1 {
+		t.Fatalf("expected one resolver call after caching, got %d", got)
+	}
+}
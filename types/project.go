@@ -47,6 +47,10 @@ type Project struct {
 	Configs    Configs    `yaml:"configs,omitempty" json:"configs,omitempty"`
 	Extensions Extensions `yaml:"#extensions,inline,omitempty" json:"-"` // https://github.com/golang/go/issues/6213
 
+	// Groups is populated from the top-level `x-groups` extension and maps
+	// a group name to the service and group names it is made of, bake-style.
+	Groups map[string][]string `yaml:"-" json:"-"`
+
 	// IncludeReferences is keyed by Compose YAML filename and contains config for
 	// other Compose YAML files it directly triggered a load of via `include`.
 	//
@@ -0,0 +1,213 @@
+/*
+   Copyright 2020 The Compose Specification Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package types
+
+import (
+	"github.com/mitchellh/copystructure"
+)
+
+// Services is the map of declared services, keyed by name.
+type Services map[string]ServiceConfig
+
+// Networks is the map of declared networks, keyed by name.
+type Networks map[string]NetworkConfig
+
+// Volumes is the map of declared volumes, keyed by name.
+type Volumes map[string]VolumeConfig
+
+// Secrets is the map of declared secrets, keyed by name.
+type Secrets map[string]SecretConfig
+
+// Configs is the map of declared configs, keyed by name.
+type Configs map[string]ConfigObjConfig
+
+// Extensions holds the `x-*` extension fields found on a Compose YAML node.
+type Extensions map[string]any
+
+// NetworkConfig is the configuration for a single network resource.
+type NetworkConfig struct {
+	Name       string     `yaml:"name,omitempty" json:"name,omitempty"`
+	Driver     string     `yaml:"driver,omitempty" json:"driver,omitempty"`
+	Extensions Extensions `yaml:"#extensions,inline,omitempty" json:"-"`
+}
+
+// VolumeConfig is the configuration for a single volume resource.
+type VolumeConfig struct {
+	Name       string     `yaml:"name,omitempty" json:"name,omitempty"`
+	Extensions Extensions `yaml:"#extensions,inline,omitempty" json:"-"`
+}
+
+// SecretConfig is the configuration for a single secret resource.
+type SecretConfig struct {
+	Name       string     `yaml:"name,omitempty" json:"name,omitempty"`
+	File       string     `yaml:"file,omitempty" json:"file,omitempty"`
+	Extensions Extensions `yaml:"#extensions,inline,omitempty" json:"-"`
+}
+
+// ConfigObjConfig is the configuration for a single config resource.
+type ConfigObjConfig struct {
+	Name       string     `yaml:"name,omitempty" json:"name,omitempty"`
+	File       string     `yaml:"file,omitempty" json:"file,omitempty"`
+	Extensions Extensions `yaml:"#extensions,inline,omitempty" json:"-"`
+}
+
+// ServiceDependency describes one entry of a service's `depends_on`.
+type ServiceDependency struct {
+	Required bool `yaml:"required,omitempty" json:"required,omitempty"`
+}
+
+// EnvFile is a single entry of a service's `env_file`.
+type EnvFile struct {
+	Path     string `yaml:"path,omitempty" json:"path,omitempty"`
+	Required bool   `yaml:"required,omitempty" json:"required,omitempty"`
+	// Format is the encoding env file content is parsed as: "dotenv" (the
+	// default), "json" or "yaml".
+	Format string `yaml:"format,omitempty" json:"format,omitempty"`
+}
+
+const (
+	// VolumeTypeVolume is the service volume `type` for a named volume.
+	VolumeTypeVolume = "volume"
+	// VolumeTypeBind is the service volume `type` for a bind mount.
+	VolumeTypeBind = "bind"
+)
+
+// ServiceVolumeConfig is a single entry of a service's `volumes`.
+type ServiceVolumeConfig struct {
+	Type   string `yaml:"type,omitempty" json:"type,omitempty"`
+	Source string `yaml:"source,omitempty" json:"source,omitempty"`
+	Target string `yaml:"target,omitempty" json:"target,omitempty"`
+}
+
+// ServiceSecretConfig is a single entry of a service's or build's `secrets`.
+type ServiceSecretConfig struct {
+	Source string `yaml:"source,omitempty" json:"source,omitempty"`
+}
+
+// ServiceConfigObjConfig is a single entry of a service's `configs`.
+type ServiceConfigObjConfig struct {
+	Source string `yaml:"source,omitempty" json:"source,omitempty"`
+}
+
+// ServiceNetworkConfig is a service's per-network attachment config.
+type ServiceNetworkConfig struct {
+	Aliases []string `yaml:"aliases,omitempty" json:"aliases,omitempty"`
+}
+
+// BuildConfig is a service's `build` section.
+type BuildConfig struct {
+	Context string                `yaml:"context,omitempty" json:"context,omitempty"`
+	Secrets []ServiceSecretConfig `yaml:"secrets,omitempty" json:"secrets,omitempty"`
+}
+
+// ServiceConfig is the configuration for a single service.
+type ServiceConfig struct {
+	Name        string                           `yaml:"-" json:"-"`
+	Image       string                           `yaml:"image,omitempty" json:"image,omitempty"`
+	Build       *BuildConfig                     `yaml:"build,omitempty" json:"build,omitempty"`
+	Environment MappingWithEquals                `yaml:"environment,omitempty" json:"environment,omitempty"`
+	EnvFiles    []EnvFile                        `yaml:"-" json:"-"`
+	DependsOn   map[string]ServiceDependency     `yaml:"depends_on,omitempty" json:"depends_on,omitempty"`
+	Networks    map[string]*ServiceNetworkConfig `yaml:"networks,omitempty" json:"networks,omitempty"`
+	Volumes     []ServiceVolumeConfig            `yaml:"volumes,omitempty" json:"volumes,omitempty"`
+	Secrets     []ServiceSecretConfig            `yaml:"secrets,omitempty" json:"secrets,omitempty"`
+	Configs     []ServiceConfigObjConfig         `yaml:"configs,omitempty" json:"configs,omitempty"`
+	Profiles    []string                         `yaml:"profiles,omitempty" json:"profiles,omitempty"`
+	Extensions  Extensions                       `yaml:"#extensions,inline,omitempty" json:"-"`
+
+	// NetworkMode, Ipc and Pid may reference another service as
+	// "service:<name>", and VolumesFrom as "<name>[:ro|:rw]", implying that
+	// service must be up first even though that isn't expressed through
+	// DependsOn.
+	NetworkMode string   `yaml:"network_mode,omitempty" json:"network_mode,omitempty"`
+	VolumesFrom []string `yaml:"volumes_from,omitempty" json:"volumes_from,omitempty"`
+	Ipc         string   `yaml:"ipc,omitempty" json:"ipc,omitempty"`
+	Pid         string   `yaml:"pid,omitempty" json:"pid,omitempty"`
+
+	// Hash is the stable content hash computed by WithConsistentHashes. It
+	// is derived state, not part of the Compose model, so it never
+	// round-trips through YAML/JSON.
+	Hash string `yaml:"-" json:"-"`
+
+	// ResolvedImages holds, per platform, the digest and raw manifest
+	// returned by WithImagesResolvedCtx. Like Hash, this is derived state.
+	ResolvedImages []ResolvedImage `yaml:"-" json:"-"`
+}
+
+func (s ServiceConfig) deepCopy() *ServiceConfig {
+	instance, err := copystructure.Copy(s)
+	if err != nil {
+		panic(err)
+	}
+	copied := instance.(ServiceConfig)
+	return &copied
+}
+
+// Mapping is a simple string to string map, e.g. decoded from a dotenv file.
+type Mapping map[string]string
+
+// Resolve looks up key in m, reporting whether it was declared. It matches
+// the `func(string) (string, bool)` lookup signature MappingWithEquals.Resolve
+// expects, so a Project's top-level Environment can be passed directly as
+// the fallback lookup when resolving a service's own environment.
+func (m Mapping) Resolve(key string) (string, bool) {
+	v, ok := m[key]
+	return v, ok
+}
+
+// ToMappingWithEquals converts m to a MappingWithEquals.
+func (m Mapping) ToMappingWithEquals() MappingWithEquals {
+	result := make(MappingWithEquals, len(m))
+	for k, v := range m {
+		v := v
+		result[k] = &v
+	}
+	return result
+}
+
+// MappingWithEquals is a mapping of environment variable names to values,
+// where a nil value means the variable is declared but unset.
+type MappingWithEquals map[string]*string
+
+// Resolve returns a copy of m where nil values are looked up via lookup.
+func (m MappingWithEquals) Resolve(lookup func(string) (string, bool)) MappingWithEquals {
+	resolved := make(MappingWithEquals, len(m))
+	for k, v := range m {
+		if v != nil {
+			resolved[k] = v
+			continue
+		}
+		if r, ok := lookup(k); ok {
+			resolved[k] = &r
+			continue
+		}
+		resolved[k] = v
+	}
+	return resolved
+}
+
+// OverrideBy returns a copy of m with every key of other overriding m's.
+func (m MappingWithEquals) OverrideBy(other MappingWithEquals) MappingWithEquals {
+	result := make(MappingWithEquals, len(m)+len(other))
+	for k, v := range m {
+		result[k] = v
+	}
+	for k, v := range other {
+		result[k] = v
+	}
+	return result
+}